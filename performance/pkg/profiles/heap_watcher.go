@@ -0,0 +1,109 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"github.com/efficientgo/tools/core/pkg/errcapture"
+)
+
+// StartHeapWatcher starts a goroutine that, every interval, forces a GC and
+// writes a timestamped heap profile (mem-<RFC3339>.pprof) into dir, pruning
+// older snapshots so that at most keep of them remain. This gives operators
+// a rolling window of heap snapshots to diff over time, without wiring up a
+// full pprof HTTP endpoint.
+//
+// keep is clamped to at least 1: the snapshot just written is always kept,
+// so a keep of 0 (or less) would otherwise delete every snapshot as soon as
+// it was written.
+//
+// The watcher stops when ctx is cancelled, flushing one final profile before
+// returning. Errors encountered while running are sent on the returned
+// channel on a best-effort basis (a full channel drops the error rather than
+// blocking the watcher), and it is closed once the watcher has stopped.
+func StartHeapWatcher(ctx context.Context, dir string, interval time.Duration, keep int) (<-chan error, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if keep < 1 {
+		keep = 1
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				if err := writeHeapSnapshot(dir, keep); err != nil {
+					sendErr(errCh, err)
+				}
+				return
+			case <-ticker.C:
+				if err := writeHeapSnapshot(dir, keep); err != nil {
+					sendErr(errCh, err)
+				}
+			}
+		}
+	}()
+	return errCh, nil
+}
+
+// sendErr delivers err on errCh without blocking, so a slow or absent
+// consumer can't stall the producing goroutine; if the channel is full, the
+// error is dropped.
+func sendErr(errCh chan<- error, err error) {
+	select {
+	case errCh <- err:
+	default:
+	}
+}
+
+// writeHeapSnapshot forces a GC, writes a timestamped heap profile to dir and
+// prunes older mem-*.pprof snapshots beyond keep.
+func writeHeapSnapshot(dir string, keep int) (err error) {
+	runtime.GC()
+
+	fileName := "mem-" + time.Now().Format(time.RFC3339) + ".pprof"
+	f, err := os.Create(filepath.Join(dir, fileName))
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, f.Close, "close")
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return err
+	}
+	return pruneHeapSnapshots(dir, keep)
+}
+
+// pruneHeapSnapshots removes the oldest mem-*.pprof files in dir, keeping at
+// most keep of the most recent ones.
+func pruneHeapSnapshots(dir string, keep int) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "mem-*.pprof"))
+	if err != nil {
+		return err
+	}
+	if len(matches) <= keep {
+		return nil
+	}
+
+	sort.Strings(matches)
+	for _, stale := range matches[:len(matches)-keep] {
+		if err := os.Remove(stale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
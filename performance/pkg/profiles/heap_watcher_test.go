@@ -0,0 +1,79 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPruneHeapSnapshots(t *testing.T) {
+	dir := t.TempDir()
+
+	names := []string{
+		"mem-2020-01-01T00:00:00Z.pprof",
+		"mem-2020-01-02T00:00:00Z.pprof",
+		"mem-2020-01-03T00:00:00Z.pprof",
+		"mem-2020-01-04T00:00:00Z.pprof",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	if err := pruneHeapSnapshots(dir, 2); err != nil {
+		t.Fatalf("pruneHeapSnapshots: %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "mem-*.pprof"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	sort.Strings(matches)
+
+	want := []string{
+		filepath.Join(dir, "mem-2020-01-03T00:00:00Z.pprof"),
+		filepath.Join(dir, "mem-2020-01-04T00:00:00Z.pprof"),
+	}
+	if len(matches) != len(want) {
+		t.Fatalf("expected %v, got %v", want, matches)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Errorf("expected remaining snapshot %s, got %s", want[i], matches[i])
+		}
+	}
+}
+
+func TestStartHeapWatcher_ClampsKeepToAtLeastOne(t *testing.T) {
+	dir := t.TempDir()
+
+	// interval is short enough to accumulate several snapshots before cancel,
+	// so a keep of 0 would (without the clamp) delete every one of them,
+	// including the one just written, instead of leaving the latest behind.
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh, err := StartHeapWatcher(ctx, dir, 10*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("StartHeapWatcher: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	for err := range errCh {
+		t.Fatalf("unexpected error from watcher: %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "mem-*.pprof"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected keep=0 to be clamped to 1 surviving snapshot, got %d: %v", len(matches), matches)
+	}
+}
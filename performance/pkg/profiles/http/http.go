@@ -0,0 +1,180 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+// Package http registers HTTP handlers under /debug/pprof and /debug/fgprof
+// backed by the profiles package writers, giving net/http/pprof-style
+// on-demand access to every profile type this module supports -- including
+// fgprof -- over a single diagnostics mux.
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/efficientgo/tools/performance/pkg/profiles"
+	"github.com/felixge/fgprof"
+	"github.com/pkg/errors"
+)
+
+// Option configures the handlers registered by Register.
+type Option func(*options)
+
+type options struct {
+	basicAuthUser string
+	basicAuthPass string
+	middleware    func(http.Handler) http.Handler
+}
+
+// WithBasicAuth requires the given username and password on every registered
+// handler, so the mux can be safely exposed on a diagnostics port.
+func WithBasicAuth(user, pass string) Option {
+	return func(o *options) {
+		o.basicAuthUser = user
+		o.basicAuthPass = pass
+	}
+}
+
+// WithMiddleware wraps every registered handler with mw, e.g. to add
+// authentication, logging or IP allow-listing.
+func WithMiddleware(mw func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = mw
+	}
+}
+
+// Register installs /debug/pprof/{heap,cpu,block,mutex,goroutine,
+// threadcreate,allocs,trace} and /debug/fgprof handlers on mux, backed by the
+// writers in the profiles package. CPU, trace and fgprof handlers accept a
+// `seconds` query parameter (default 30) controlling how long they sample for.
+func Register(mux *http.ServeMux, opts ...Option) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mux.Handle("/debug/pprof/heap", o.wrap(http.HandlerFunc(heapHandler)))
+	mux.Handle("/debug/pprof/block", o.wrap(snapshotHandler(profiles.Block, "block.pprof")))
+	mux.Handle("/debug/pprof/mutex", o.wrap(snapshotHandler(profiles.Mutex, "mutex.pprof")))
+	mux.Handle("/debug/pprof/goroutine", o.wrap(snapshotHandler(profiles.Goroutine, "goroutine.pprof")))
+	mux.Handle("/debug/pprof/threadcreate", o.wrap(snapshotHandler(profiles.Threadcreate, "threadcreate.pprof")))
+	mux.Handle("/debug/pprof/allocs", o.wrap(snapshotHandler(profiles.Allocs, "allocs.pprof")))
+	mux.Handle("/debug/pprof/cpu", o.wrap(http.HandlerFunc(cpuHandler)))
+	mux.Handle("/debug/pprof/trace", o.wrap(http.HandlerFunc(traceHandler)))
+	mux.Handle("/debug/fgprof", o.wrap(http.HandlerFunc(fgprofHandler)))
+}
+
+// wrap applies basic auth (if configured) and the user middleware (if any) to h.
+func (o options) wrap(h http.Handler) http.Handler {
+	if o.middleware != nil {
+		h = o.middleware(h)
+	}
+	if o.basicAuthUser != "" {
+		inner := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(o.basicAuthUser)) == 1
+			passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(o.basicAuthPass)) == 1
+			if !ok || !userMatch || !passMatch {
+				w.Header().Set("WWW-Authenticate", `Basic realm="profiles"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+	return h
+}
+
+// snapshotHandler wraps a dir-based profile writer (e.g. profiles.Block) into
+// an HTTP handler that writes the profile to a temporary directory and
+// streams the resulting file back to the caller.
+func snapshotHandler(write func(dir string) error, fileName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir, err := os.MkdirTemp("", "profiles-http-*")
+		if err != nil {
+			http.Error(w, errors.Wrap(err, "create temp dir").Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		if err := write(dir); err != nil {
+			http.Error(w, errors.Wrapf(err, "write %s", fileName).Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.google.protobuf")
+		http.ServeFile(w, r, dir+string(os.PathSeparator)+fileName)
+	})
+}
+
+// responseSink is a profiles.Sink that streams the written profile straight
+// to an HTTP response, used by the handlers that were rewired onto the
+// Sink-based profiles API (heap, cpu, trace).
+type responseSink struct {
+	w http.ResponseWriter
+}
+
+func (s *responseSink) Write(_ context.Context, _ string, _ time.Time, r io.Reader) error {
+	_, err := io.Copy(s.w, r)
+	return err
+}
+
+func heapHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/vnd.google.protobuf")
+	if err := profiles.Heap(r.Context(), &responseSink{w: w}); err != nil {
+		http.Error(w, errors.Wrap(err, "write heap profile").Error(), http.StatusInternalServerError)
+	}
+}
+
+func cpuHandler(w http.ResponseWriter, r *http.Request) {
+	closeFn, err := profiles.StartCPU(r.Context(), &responseSink{w: w}, profiles.CPUTypeBuiltIn)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "start CPU profile").Error(), http.StatusInternalServerError)
+		return
+	}
+	time.Sleep(seconds(r))
+
+	w.Header().Set("Content-Type", "application/vnd.google.protobuf")
+	if err := closeFn(); err != nil {
+		http.Error(w, errors.Wrap(err, "stop CPU profile").Error(), http.StatusInternalServerError)
+	}
+}
+
+func traceHandler(w http.ResponseWriter, r *http.Request) {
+	closeFn, err := profiles.StartTrace(r.Context(), &responseSink{w: w})
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "start trace").Error(), http.StatusInternalServerError)
+		return
+	}
+	time.Sleep(seconds(r))
+
+	if err := closeFn(); err != nil {
+		http.Error(w, errors.Wrap(err, "stop trace").Error(), http.StatusInternalServerError)
+	}
+}
+
+// fgprofHandler streams an fgprof profile for `seconds` directly to the
+// response, bridging the fgprof integration into the same on-demand HTTP
+// surface net/http/pprof provides for the built-in profiles.
+func fgprofHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/vnd.google.protobuf")
+	stop := fgprof.Start(w, fgprof.FormatPprof)
+	time.Sleep(seconds(r))
+	if err := stop(); err != nil {
+		http.Error(w, errors.Wrap(err, "stop fgprof").Error(), http.StatusInternalServerError)
+	}
+}
+
+// seconds parses the `seconds` query parameter, defaulting to 30.
+func seconds(r *http.Request) time.Duration {
+	const def = 30
+	n, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || n <= 0 {
+		n = def
+	}
+	return time.Duration(n) * time.Second
+}
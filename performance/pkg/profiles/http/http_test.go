@@ -0,0 +1,68 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegister_BasicAuth(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, WithBasicAuth("user", "pass"))
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name       string
+		user, pass string
+		set        bool
+		wantStatus int
+	}{
+		{"no credentials", "", "", false, http.StatusUnauthorized},
+		{"wrong user", "nope", "pass", true, http.StatusUnauthorized},
+		{"wrong pass", "user", "nope", true, http.StatusUnauthorized},
+		{"correct credentials", "user", "pass", true, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/debug/pprof/goroutine", nil)
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			if tc.set {
+				req.SetBasicAuth(tc.user, tc.pass)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d", tc.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRegister_NoBasicAuthByDefault(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/pprof/goroutine")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
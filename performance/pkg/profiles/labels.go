@@ -0,0 +1,53 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+)
+
+// WithLabels attaches the given key/value label pairs (as alternating
+// "key", "value" arguments, matching runtime/pprof.Labels) to ctx, so that
+// goroutines started from it are tagged in CPU and goroutine profiles
+// collected via StartCPU / Goroutine. The labels can then be used to filter
+// or group samples by tag in pprof.
+func WithLabels(ctx context.Context, labels ...string) context.Context {
+	return pprof.WithLabels(ctx, pprof.Labels(labels...))
+}
+
+// Do runs fn with the given labels (see WithLabels) attached to ctx for the
+// duration of the call, and additionally sets them as the goroutine labels
+// of the calling goroutine so profiles taken while fn runs are tagged too.
+func Do(ctx context.Context, fn func(ctx context.Context), labels ...string) {
+	pprof.Do(ctx, pprof.Labels(labels...), fn)
+}
+
+// WithLabelsForDuration sets labels as the pprof labels of the calling
+// goroutine for the duration of fn, then restores ctx's previous labels.
+// Unlike Do, fn receives no derived ctx, and this also locks the calling
+// goroutine to its current OS thread for the duration of fn, so CPU samples
+// taken in cgo/syscall paths invoked synchronously from fn are labeled even
+// though they don't carry a context.
+func WithLabelsForDuration(ctx context.Context, labels []string, fn func()) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	defer pprof.SetGoroutineLabels(ctx)
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(labels...)))
+	fn()
+}
+
+// LabelHTTPMiddleware wraps next so that each request's goroutine is labeled
+// with labelFn(r) for the duration of the request, letting CPU and goroutine
+// profiles be filtered or grouped per-tenant or per-endpoint.
+func LabelHTTPMiddleware(next http.Handler, labelFn func(*http.Request) []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Do(r.Context(), func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}, labelFn(r)...)
+	})
+}
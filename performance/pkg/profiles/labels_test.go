@@ -0,0 +1,75 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+func labelValue(ctx context.Context, key string) (string, bool) {
+	var (
+		val   string
+		found bool
+	)
+	pprof.ForLabels(ctx, func(k, v string) bool {
+		if k == key {
+			val, found = v, true
+			return false
+		}
+		return true
+	})
+	return val, found
+}
+
+func TestDo_LabelsVisibleOnlyInsideFn(t *testing.T) {
+	var val string
+	var ok bool
+	Do(context.Background(), func(ctx context.Context) {
+		val, ok = labelValue(ctx, "endpoint")
+	}, "endpoint", "/debug/pprof/heap")
+
+	if !ok || val != "/debug/pprof/heap" {
+		t.Fatalf("expected label %q inside Do, got %q (found=%v)", "/debug/pprof/heap", val, ok)
+	}
+
+	if _, ok := labelValue(context.Background(), "endpoint"); ok {
+		t.Fatalf("expected no label on an unrelated context")
+	}
+}
+
+func TestWithLabelsForDuration_RunsFnAndLeavesCtxUntouched(t *testing.T) {
+	ctx := WithLabels(context.Background(), "tenant", "before")
+
+	var ran bool
+	WithLabelsForDuration(ctx, []string{"tenant", "during"}, func() {
+		ran = true
+	})
+	if !ran {
+		t.Fatalf("expected fn to run")
+	}
+
+	// WithLabelsForDuration only mutates the calling goroutine's pprof
+	// labels, not ctx itself, so ctx's own label set must be unchanged
+	// after the call.
+	val, ok := labelValue(ctx, "tenant")
+	if !ok || val != "before" {
+		t.Fatalf("expected ctx to still carry label %q, got %q (found=%v)", "before", val, ok)
+	}
+}
+
+func TestWithLabelsForDuration_UsableFromMultipleGoroutines(t *testing.T) {
+	const n = 10
+	done := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			WithLabelsForDuration(context.Background(), []string{"worker", "x"}, func() {})
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-done
+	}
+}
@@ -4,12 +4,14 @@
 package profiles
 
 import (
-	"fmt"
+	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
+	"time"
 
 	"github.com/efficientgo/tools/core/pkg/errcapture"
 	"github.com/felixge/fgprof"
@@ -36,20 +38,15 @@ func StartHeapRecording(everyAlloc bool) {
 	runtime.MemProfileRate = 512 * 1024
 }
 
-// Heap creates a heap profile in mem.pprof file in given directory.
-// Directory might be non-existent, heap will create it if needed.
-// NOTE: Given the runtime implementation this setting is global.
-func Heap(dir string) (err error) {
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return err
-	}
-
-	f, err := os.Create(filepath.Join(dir, "mem.pprof"))
-	if err != nil {
+// Heap writes a heap profile to sink, tagged with kind "heap" (mem.pprof
+// when sink is a FileSink, matching the previous dir-based behavior).
+// NOTE: Given the runtime implementation the memory profile rate is global.
+func Heap(ctx context.Context, sink Sink) error {
+	var buf bytes.Buffer
+	if err := pprof.WriteHeapProfile(&buf); err != nil {
 		return err
 	}
-	defer errcapture.Do(&err, f.Close, "close")
-	return pprof.WriteHeapProfile(f)
+	return sink.Write(ctx, "heap", time.Now(), &buf)
 }
 
 type CPUType string
@@ -60,70 +57,125 @@ const (
 	CPUTypeFGProf CPUType = "fgprof"
 )
 
-// StartCPU starts CPU profiling. If no error is returned, it returns close function that stops and flushes
-// profile to cpu.pprof or cpu.fgprof.pprof file in a given directory.
-// Directory might be non-existent, heap will create it if needed.
-// NOTE: Given the runtime implementation this setting is global.
-func StartCPU(dir string, typ CPUType) (closeFn func() error, err error) {
-	fileName := "cpu.pprof"
-	switch typ {
-	case CPUTypeBuiltIn:
-	case CPUTypeFGProf:
-		fileName = "cpu.fgprof.pprof"
-	default:
-		return nil, errors.Errorf("unknown CPU profile type %v", typ)
-	}
-
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return nil, err
-	}
-
-	f, err := os.Create(filepath.Join(dir, fileName))
-	if err != nil {
-		return nil, err
-	}
+// StartCPU starts CPU profiling. If no error is returned, it returns a close
+// function that stops profiling and hands the result to sink, tagged with
+// kind "cpu" or "cpu.fgprof" depending on typ (cpu.pprof / cpu.fgprof.pprof
+// when sink is a FileSink, matching the previous dir-based behavior).
+func StartCPU(ctx context.Context, sink Sink, typ CPUType) (closeFn func() error, err error) {
+	var buf bytes.Buffer
 
 	switch typ {
 	case CPUTypeBuiltIn:
-		if err = pprof.StartCPUProfile(f); err != nil {
-			errcapture.Do(&err, f.Close, fmt.Sprintf("close %v", filepath.Join(dir, fileName)))
+		if err := pprof.StartCPUProfile(&buf); err != nil {
 			return nil, err
 		}
-		closeFn = func() (ferr error) {
+		closeFn = func() error {
 			pprof.StopCPUProfile()
-			return errors.Wrapf(f.Close(), "close %v", filepath.Join(dir, fileName))
+			return sink.Write(ctx, "cpu", time.Now(), &buf)
 		}
 	case CPUTypeFGProf:
-		closeFGProfFn := fgprof.Start(f, fgprof.FormatPprof)
-		closeFn = func() (ferr error) {
-			defer errcapture.Do(&ferr, f.Close, fmt.Sprintf("close %v", filepath.Join(dir, fileName)))
-			return closeFGProfFn()
+		closeFGProfFn := fgprof.Start(&buf, fgprof.FormatPprof)
+		closeFn = func() error {
+			if err := closeFGProfFn(); err != nil {
+				return err
+			}
+			return sink.Write(ctx, "cpu.fgprof", time.Now(), &buf)
 		}
+	default:
+		return nil, errors.Errorf("unknown CPU profile type %v", typ)
 	}
 	return closeFn, nil
 }
 
-// StartTrace starts tracingIf no error is returned, it returns close function that stops and flushes
-// profile to trace.out file in a given directory.
-// Directory might be non-existent, heap will create it if needed.
+// StartTrace starts an execution trace. If no error is returned, it returns
+// a close function that stops tracing and hands the result to sink, tagged
+// with kind "trace" (trace.out when sink is a FileSink, matching the
+// previous dir-based behavior).
+func StartTrace(ctx context.Context, sink Sink) (closeFn func() error, err error) {
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		return nil, err
+	}
+	return func() error {
+		trace.Stop()
+		return sink.Write(ctx, "trace", time.Now(), &buf)
+	}, nil
+}
+
+// StopBlockProfiling stops block profiling, which will mean that contention
+// events after this function will not be recorded in any block profile until
+// we resume it.
 // NOTE: Given the runtime implementation this setting is global.
-func StartTrace(dir string) (closeFn func() error, err error) {
-	fileName := "trace.out"
+func StopBlockProfiling() {
+	runtime.SetBlockProfileRate(0)
+}
+
+// StartBlockProfiling enables block profiling, reporting goroutine blocking
+// events such as select and channel send/receive with the given sampling
+// rate, in nanoseconds: the profiler samples on average one blocking event
+// per rate nanoseconds spent blocked, and a rate of 1 records every event.
+// NOTE: Given the runtime implementation this setting is global.
+func StartBlockProfiling(rate int) {
+	runtime.SetBlockProfileRate(rate)
+}
+
+// StopMutexProfiling stops mutex profiling, which will mean that contention
+// events after this function will not be recorded in any mutex profile until
+// we resume it.
+// NOTE: Given the runtime implementation this setting is global.
+func StopMutexProfiling() {
+	runtime.SetMutexProfileFraction(0)
+}
+
+// StartMutexProfiling enables mutex profiling, reporting a fraction of
+// 1/fraction of mutex contention events.
+// NOTE: Given the runtime implementation this setting is global.
+func StartMutexProfiling(fraction int) {
+	runtime.SetMutexProfileFraction(fraction)
+}
+
+// Block creates a block profile in block.pprof file in given directory.
+// Directory might be non-existent, Block will create it if needed.
+// NOTE: StartBlockProfiling has to be called first, otherwise the profile will be empty.
+func Block(dir string) error {
+	return lookupWriteTo(dir, "block", "block.pprof")
+}
+
+// Mutex creates a mutex profile in mutex.pprof file in given directory.
+// Directory might be non-existent, Mutex will create it if needed.
+// NOTE: StartMutexProfiling has to be called first, otherwise the profile will be empty.
+func Mutex(dir string) error {
+	return lookupWriteTo(dir, "mutex", "mutex.pprof")
+}
+
+// Goroutine creates a goroutine profile in goroutine.pprof file in given directory.
+// Directory might be non-existent, Goroutine will create it if needed.
+func Goroutine(dir string) error {
+	return lookupWriteTo(dir, "goroutine", "goroutine.pprof")
+}
 
+// Threadcreate creates a threadcreate profile in threadcreate.pprof file in given directory.
+// Directory might be non-existent, Threadcreate will create it if needed.
+func Threadcreate(dir string) error {
+	return lookupWriteTo(dir, "threadcreate", "threadcreate.pprof")
+}
+
+// Allocs creates an allocs profile in allocs.pprof file in given directory.
+// Directory might be non-existent, Allocs will create it if needed.
+func Allocs(dir string) error {
+	return lookupWriteTo(dir, "allocs", "allocs.pprof")
+}
+
+// lookupWriteTo writes the named runtime/pprof profile to fileName in dir.
+func lookupWriteTo(dir, profile, fileName string) (err error) {
 	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return nil, err
+		return err
 	}
 
 	f, err := os.Create(filepath.Join(dir, fileName))
 	if err != nil {
-		return nil, err
-	}
-
-	if err := trace.Start(f); err != nil {
-		return nil, err
+		return err
 	}
-	return func() error {
-		trace.Stop()
-		return errors.Wrapf(f.Close(), "close %v", filepath.Join(dir, fileName))
-	}, nil
+	defer errcapture.Do(&err, f.Close, "close")
+	return pprof.Lookup(profile).WriteTo(f, 0)
 }
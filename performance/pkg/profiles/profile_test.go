@@ -0,0 +1,41 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupWriteTo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		write    func(dir string) error
+		fileName string
+	}{
+		{"block", Block, "block.pprof"},
+		{"mutex", Mutex, "mutex.pprof"},
+		{"goroutine", Goroutine, "goroutine.pprof"},
+		{"threadcreate", Threadcreate, "threadcreate.pprof"},
+		{"allocs", Allocs, "allocs.pprof"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sub := filepath.Join(dir, "nested")
+
+			if err := tc.write(sub); err != nil {
+				t.Fatalf("%s(%s) returned error: %v", tc.name, sub, err)
+			}
+
+			fi, err := os.Stat(filepath.Join(sub, tc.fileName))
+			if err != nil {
+				t.Fatalf("expected %s to be created: %v", tc.fileName, err)
+			}
+			if fi.Size() == 0 {
+				t.Fatalf("expected %s to be non-empty", tc.fileName)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"github.com/efficientgo/tools/core/pkg/merrors"
+)
+
+// Option configures a Session.
+type Option func(*sessionOptions)
+
+type sessionOptions struct {
+	dir string
+
+	cpu           bool
+	cpuType       CPUType
+	trace         bool
+	heap          bool
+	memRate       int
+	block         bool
+	blockRate     int
+	mutex         bool
+	mutexFraction int
+
+	quiet        bool
+	shutdownHook bool
+}
+
+// WithCPU enables CPU profiling of the given type for the session.
+func WithCPU(typ CPUType) Option {
+	return func(o *sessionOptions) {
+		o.cpu = true
+		o.cpuType = typ
+	}
+}
+
+// WithTrace enables execution trace collection for the session.
+func WithTrace() Option {
+	return func(o *sessionOptions) {
+		o.trace = true
+	}
+}
+
+// WithHeap enables a heap profile to be written on Stop.
+func WithHeap() Option {
+	return func(o *sessionOptions) {
+		o.heap = true
+	}
+}
+
+// WithMemProfileRate sets the memory profile rate (see StartHeapRecording) for
+// the duration of the session. If not set, the runtime default is left untouched.
+func WithMemProfileRate(rate int) Option {
+	return func(o *sessionOptions) {
+		o.memRate = rate
+	}
+}
+
+// WithBlockRate enables block profiling with the given rate for the duration
+// of the session (see StartBlockProfiling).
+func WithBlockRate(rate int) Option {
+	return func(o *sessionOptions) {
+		o.block = true
+		o.blockRate = rate
+	}
+}
+
+// WithMutexFraction enables mutex profiling with the given fraction for the
+// duration of the session (see StartMutexProfiling).
+func WithMutexFraction(fraction int) Option {
+	return func(o *sessionOptions) {
+		o.mutex = true
+		o.mutexFraction = fraction
+	}
+}
+
+// WithQuiet suppresses the informational message Start prints about where
+// profiles will be written.
+func WithQuiet() Option {
+	return func(o *sessionOptions) {
+		o.quiet = true
+	}
+}
+
+// WithShutdownHook toggles the SIGINT/SIGTERM hook that Start installs by
+// default, which calls Stop and re-raises the signal once profiles are
+// flushed. Pass false to manage shutdown yourself.
+func WithShutdownHook(enabled bool) Option {
+	return func(o *sessionOptions) {
+		o.shutdownHook = enabled
+	}
+}
+
+// Session is a running set of profile collectors started by Start. Stop
+// flushes every enabled profile and restores any global runtime state
+// (MemProfileRate, block/mutex profile rate) that was changed for the
+// session.
+type Session struct {
+	opts sessionOptions
+	sink Sink
+
+	prevMemProfileRate int
+
+	cpuCloseFn   func() error
+	traceCloseFn func() error
+
+	sigCh chan os.Signal
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// Start begins a profiling session in dir, with the profile types and
+// settings selected by opts. It replaces the boilerplate of creating dirs,
+// starting each profile type and deferring its close individually: callers
+// just defer Stop.
+//
+// Unless WithShutdownHook(false) is passed, Start installs a SIGINT/SIGTERM
+// handler that calls Stop and re-raises the signal, so profiles are flushed
+// on Ctrl-C even if the caller never reaches their own defer.
+func Start(dir string, opts ...Option) (_ *Session, err error) {
+	o := sessionOptions{
+		dir:          dir,
+		shutdownHook: true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !o.quiet {
+		fmt.Printf("profiles: writing profiles to %s\n", o.dir)
+	}
+
+	s := &Session{opts: o, sink: NewFileSink(o.dir)}
+	defer func() {
+		if err != nil {
+			_ = s.Stop()
+		}
+	}()
+
+	if o.memRate != 0 {
+		s.prevMemProfileRate = runtime.MemProfileRate
+		runtime.MemProfileRate = o.memRate
+	}
+	if o.block {
+		StartBlockProfiling(o.blockRate)
+	}
+	if o.mutex {
+		StartMutexProfiling(o.mutexFraction)
+	}
+	if o.cpu {
+		s.cpuCloseFn, err = StartCPU(context.Background(), s.sink, o.cpuType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.trace {
+		s.traceCloseFn, err = StartTrace(context.Background(), s.sink)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.shutdownHook {
+		s.sigCh = make(chan os.Signal, 1)
+		signal.Notify(s.sigCh, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			sig, ok := <-s.sigCh
+			if !ok {
+				return
+			}
+			_ = s.Stop()
+			signal.Stop(s.sigCh)
+			process, perr := os.FindProcess(os.Getpid())
+			if perr == nil {
+				_ = process.Signal(sig)
+			}
+		}()
+	}
+
+	return s, nil
+}
+
+// Stop flushes all profiles enabled for the session and restores any global
+// runtime state they changed, aggregating all errors encountered. Stop may be
+// called more than once, including concurrently (e.g. from both the
+// installed shutdown hook and a caller's own defer); only the first call
+// does any work, and all callers observe its result.
+func (s *Session) Stop() error {
+	s.stopOnce.Do(func() {
+		errs := merrors.New()
+
+		if s.sigCh != nil {
+			signal.Stop(s.sigCh)
+			close(s.sigCh)
+		}
+
+		if s.cpuCloseFn != nil {
+			errs.Add(s.cpuCloseFn())
+		}
+		if s.traceCloseFn != nil {
+			errs.Add(s.traceCloseFn())
+		}
+		if s.opts.heap {
+			errs.Add(Heap(context.Background(), s.sink))
+		}
+		if s.opts.memRate != 0 {
+			runtime.MemProfileRate = s.prevMemProfileRate
+		}
+		if s.opts.block {
+			errs.Add(Block(s.opts.dir))
+			StopBlockProfiling()
+		}
+		if s.opts.mutex {
+			errs.Add(Mutex(s.opts.dir))
+			StopMutexProfiling()
+		}
+
+		s.stopErr = errs.Err()
+	})
+	return s.stopErr
+}
@@ -0,0 +1,64 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSession_StopIsIdempotent(t *testing.T) {
+	s, err := Start(t.TempDir(), WithHeap(), WithQuiet(), WithShutdownHook(false))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("first Stop: %v", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("second Stop should be a no-op, got: %v", err)
+	}
+}
+
+func TestSession_RestoresMemProfileRate(t *testing.T) {
+	const prior = 12345
+	runtime.MemProfileRate = prior
+	defer func() { runtime.MemProfileRate = prior }()
+
+	s, err := Start(t.TempDir(), WithMemProfileRate(1), WithQuiet(), WithShutdownHook(false))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if runtime.MemProfileRate != 1 {
+		t.Fatalf("expected MemProfileRate to be set to 1, got %d", runtime.MemProfileRate)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if runtime.MemProfileRate != prior {
+		t.Fatalf("expected MemProfileRate to be restored to %d, got %d", prior, runtime.MemProfileRate)
+	}
+}
+
+func TestSession_FlushesBlockAndMutexProfilesOnStop(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Start(dir, WithBlockRate(1), WithMutexFraction(1), WithQuiet(), WithShutdownHook(false))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for _, fileName := range []string{"block.pprof", "mutex.pprof"} {
+		if _, err := os.Stat(filepath.Join(dir, fileName)); err != nil {
+			t.Errorf("expected %s to be written by Stop: %v", fileName, err)
+		}
+	}
+}
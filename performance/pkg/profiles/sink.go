@@ -0,0 +1,206 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sink receives a completed profile of the given kind (e.g. "heap", "cpu",
+// "cpu.fgprof", "trace") captured at ts. Implementations decide where the
+// bytes end up: on local disk, in object storage, or shipped to a remote
+// ingest endpoint.
+type Sink interface {
+	Write(ctx context.Context, kind string, ts time.Time, r io.Reader) error
+}
+
+// kindFileNames maps a profile kind to the on-disk file name FileSink
+// historically wrote it to, keeping FileSink byte-for-byte compatible with
+// the directory layout the earlier dir-based API produced.
+var kindFileNames = map[string]string{
+	"heap":       "mem.pprof",
+	"cpu":        "cpu.pprof",
+	"cpu.fgprof": "cpu.fgprof.pprof",
+	"trace":      "trace.out",
+}
+
+// FileSink writes profiles as files into Dir, which is created if it does
+// not yet exist. It is the Sink used under the hood by the dir-based
+// profiles API that predates Sink.
+//
+// By default it writes each kind to a fixed file name (e.g. mem.pprof),
+// matching the previous dir-based behavior, so repeated writes of the same
+// kind overwrite the previous one. Set Timestamped to include the capture
+// time in the file name instead; this is required when using FileSink as
+// the sink for StartContinuous, otherwise each sample silently replaces the
+// last one and no history is kept.
+type FileSink struct {
+	Dir         string
+	Timestamped bool
+}
+
+// NewFileSink returns a FileSink writing into dir.
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{Dir: dir}
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(_ context.Context, kind string, ts time.Time, r io.Reader) (err error) {
+	if err := os.MkdirAll(s.Dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	fileName, ok := kindFileNames[kind]
+	if !ok {
+		fileName = kind + ".pprof"
+	}
+	if s.Timestamped {
+		ext := filepath.Ext(fileName)
+		base := strings.TrimSuffix(fileName, ext)
+		fileName = fmt.Sprintf("%s-%s%s", base, ts.Format(time.RFC3339), ext)
+	}
+
+	f, err := os.Create(filepath.Join(s.Dir, fileName))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = errors.Wrapf(cerr, "close %v", filepath.Join(s.Dir, fileName))
+		}
+	}()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// S3Uploader is the minimal interface FileSink's S3 counterpart needs,
+// typically a small adapter around an S3 client such as
+// github.com/aws/aws-sdk-go-v2/feature/s3/manager.Uploader, whose own Upload
+// method has a different signature.
+type S3Uploader interface {
+	Upload(ctx context.Context, bucket, key string, body io.Reader) error
+}
+
+// S3Sink uploads profiles to an S3-compatible bucket, keyed by
+// "<Prefix><kind>-<ts RFC3339>.pprof".
+type S3Sink struct {
+	Uploader S3Uploader
+	Bucket   string
+	Prefix   string
+}
+
+// Write implements Sink.
+func (s *S3Sink) Write(ctx context.Context, kind string, ts time.Time, r io.Reader) error {
+	key := fmt.Sprintf("%s%s-%s.pprof", s.Prefix, kind, ts.Format(time.RFC3339))
+	return s.Uploader.Upload(ctx, s.Bucket, key, r)
+}
+
+// HTTPSink POSTs profiles to URL, tagging each request with
+// X-Profile-Kind and X-Profile-Timestamp headers so the receiving end can
+// route or label the upload without parsing the body.
+type HTTPSink struct {
+	Client *http.Client
+	URL    string
+}
+
+// Write implements Sink.
+func (s *HTTPSink) Write(ctx context.Context, kind string, ts time.Time, r io.Reader) error {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.google.protobuf")
+	req.Header.Set("X-Profile-Kind", kind)
+	req.Header.Set("X-Profile-Timestamp", ts.Format(time.RFC3339))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("profile sink: unexpected status %v from %v", resp.Status, s.URL)
+	}
+	return nil
+}
+
+// ContinuousConfig controls the sampling cadence of StartContinuous.
+type ContinuousConfig struct {
+	// CPUEvery is the interval between CPU profile collections. Zero disables CPU sampling.
+	CPUEvery time.Duration
+	// CPUDuration is how long each CPU sample runs for; it must be smaller than CPUEvery.
+	CPUDuration time.Duration
+	// HeapEvery is the interval between heap profile collections. Zero disables heap sampling.
+	HeapEvery time.Duration
+}
+
+// StartContinuous repeatedly collects short CPU and/or heap samples and
+// ships them to sink according to cfg, enabling "always-on" profiling for a
+// long-running service without the caller having to build the collection
+// loop. It stops when ctx is cancelled; errors are sent on the returned
+// channel on a best-effort basis (a full channel drops the error rather than
+// blocking the loop), and the channel is closed once the loop has stopped.
+//
+// If sink is a *FileSink, set its Timestamped field so each sample gets its
+// own file; otherwise every sample overwrites the last one.
+func StartContinuous(ctx context.Context, sink Sink, cfg ContinuousConfig) (<-chan error, error) {
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(errCh)
+
+		var cpuTicker, heapTicker *time.Ticker
+		var cpuCh, heapCh <-chan time.Time
+		if cfg.CPUEvery > 0 {
+			cpuTicker = time.NewTicker(cfg.CPUEvery)
+			defer cpuTicker.Stop()
+			cpuCh = cpuTicker.C
+		}
+		if cfg.HeapEvery > 0 {
+			heapTicker = time.NewTicker(cfg.HeapEvery)
+			defer heapTicker.Stop()
+			heapCh = heapTicker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-cpuCh:
+				closeFn, err := StartCPU(ctx, sink, CPUTypeBuiltIn)
+				if err != nil {
+					sendErr(errCh, err)
+					continue
+				}
+				select {
+				case <-time.After(cfg.CPUDuration):
+				case <-ctx.Done():
+				}
+				if err := closeFn(); err != nil {
+					sendErr(errCh, err)
+				}
+			case <-heapCh:
+				if err := Heap(ctx, sink); err != nil {
+					sendErr(errCh, err)
+				}
+			}
+		}
+	}()
+
+	return errCh, nil
+}
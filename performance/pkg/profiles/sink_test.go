@@ -0,0 +1,77 @@
+// Copyright (c) The EfficientGo Authors.
+// Licensed under the Apache License 2.0.
+
+package profiles
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSink_FixedFileNameOverwritesPreviousWrite(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSink(dir)
+
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := sink.Write(context.Background(), "heap", ts, bytes.NewReader([]byte("first"))); err != nil {
+		t.Fatalf("first Write: %v", err)
+	}
+	if err := sink.Write(context.Background(), "heap", ts.Add(time.Hour), bytes.NewReader([]byte("second"))); err != nil {
+		t.Fatalf("second Write: %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "mem*.pprof"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected a single fixed-name file, got %v", matches)
+	}
+
+	got, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(got) != "second" {
+		t.Fatalf("expected the second write to overwrite the first, got %q", got)
+	}
+}
+
+func TestFileSink_TimestampedKeepsEachWrite(t *testing.T) {
+	dir := t.TempDir()
+	sink := &FileSink{Dir: dir, Timestamped: true}
+
+	ts1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts2 := ts1.Add(time.Hour)
+	if err := sink.Write(context.Background(), "heap", ts1, bytes.NewReader([]byte("first"))); err != nil {
+		t.Fatalf("first Write: %v", err)
+	}
+	if err := sink.Write(context.Background(), "heap", ts2, bytes.NewReader([]byte("second"))); err != nil {
+		t.Fatalf("second Write: %v", err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "mem-*.pprof"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected both timestamped writes to be kept, got %v", matches)
+	}
+}
+
+func TestFileSink_UnknownKindFallsBackToKindDotPprof(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSink(dir)
+
+	if err := sink.Write(context.Background(), "custom", time.Now(), bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "custom.pprof")); err != nil {
+		t.Fatalf("expected custom.pprof to be created: %v", err)
+	}
+}